@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// credentials is the request body for register/login.
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Handlers wires the user store and session secrets needed to serve
+// /api/auth/register, /api/auth/login, and /api/auth/me.
+type Handlers struct {
+	Users   *UserStore
+	Secrets Secrets
+}
+
+// RegisterHandler handles POST /api/auth/register: it creates a new user
+// and, on success, logs them in by issuing a session cookie.
+func (h *Handlers) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	var creds credentials
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil || creds.Username == "" || creds.Password == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Bad request")
+		return
+	}
+
+	user, err := h.Users.Register(creds.Username, creds.Password)
+	if errors.Is(err, ErrUsernameTaken) {
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, "Username already taken")
+		return
+	} else if err != nil {
+		slog.Error("register user", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+
+	http.SetCookie(w, NewSessionCookie(user.ID, h.Secrets))
+	writeUser(w, http.StatusCreated, user)
+}
+
+// LoginHandler handles POST /api/auth/login: it verifies credentials and,
+// on success, issues a session cookie.
+func (h *Handlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var creds credentials
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Bad request")
+		return
+	}
+
+	user, err := h.Users.Authenticate(creds.Username, creds.Password)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, "Invalid username or password")
+		return
+	}
+
+	http.SetCookie(w, NewSessionCookie(user.ID, h.Secrets))
+	writeUser(w, http.StatusOK, user)
+}
+
+// MeHandler handles GET /api/auth/me: it returns the authenticated user.
+// It must run behind AuthMiddleware.
+func (h *Handlers) MeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, "Unauthorized")
+		return
+	}
+
+	user, err := h.Users.Get(userID)
+	if errors.Is(err, ErrUserNotFound) {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, "Unauthorized")
+		return
+	} else if err != nil {
+		slog.Error("get user", "error", err, "id", userID)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+
+	writeUser(w, http.StatusOK, user)
+}
+
+// writeUser responds with the public fields of user: never the password
+// hash.
+func writeUser(w http.ResponseWriter, status int, user *User) {
+	body, err := json.Marshal(struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	}{ID: user.ID, Username: user.Username})
+	if err != nil {
+		slog.Error("marshal user", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
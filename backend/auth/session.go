@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SessionCookieName is the cookie carrying a signed session token.
+const SessionCookieName = "crm_session"
+
+// SessionTTL is how long a session cookie remains valid after it is issued.
+const SessionTTL = 7 * 24 * time.Hour
+
+// Secrets holds the keys used to sign and verify session cookies. Current
+// is used to sign new cookies; Previous is accepted when verifying existing
+// ones, so a secret can be rotated without invalidating every session in
+// flight: deploy with the new secret prepended and the old one kept in
+// Previous, then drop it once its sessions have expired.
+type Secrets struct {
+	Current  []byte
+	Previous [][]byte
+}
+
+// LoadSecrets parses session signing secrets from env, a comma-separated
+// list such as the value of an environment variable. The first value is
+// used to sign new cookies; any
+// remaining values are accepted (but never used to sign) during
+// verification, to support rotation.
+func LoadSecrets(env string) (Secrets, error) {
+	raw := strings.Split(env, ",")
+	if len(raw) == 0 || raw[0] == "" {
+		return Secrets{}, fmt.Errorf("auth: no session secret configured")
+	}
+
+	secrets := Secrets{Current: []byte(raw[0])}
+	for _, s := range raw[1:] {
+		if s != "" {
+			secrets.Previous = append(secrets.Previous, []byte(s))
+		}
+	}
+	return secrets, nil
+}
+
+// all returns every secret Secrets will accept during verification, current
+// first.
+func (s Secrets) all() [][]byte {
+	return append([][]byte{s.Current}, s.Previous...)
+}
+
+// sessionPayload is "<userID>.<expiryUnixSeconds>", the data an HMAC signs.
+func sessionPayload(userID string, expiry time.Time) string {
+	return userID + "." + strconv.FormatInt(expiry.Unix(), 10)
+}
+
+func sign(payload string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSessionCookie builds a signed session cookie for userID, valid for
+// SessionTTL.
+func NewSessionCookie(userID string, secrets Secrets) *http.Cookie {
+	expiry := time.Now().Add(SessionTTL)
+	payload := sessionPayload(userID, expiry)
+	token := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + sign(payload, secrets.Current)
+
+	return &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  expiry,
+	}
+}
+
+// ParseSessionCookie verifies token's signature against any of secrets and
+// returns the user id it names, or an error if the signature is invalid or
+// the session has expired.
+func ParseSessionCookie(token string, secrets Secrets) (string, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("auth: malformed session token")
+	}
+
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("auth: malformed session token: %w", err)
+	}
+	payload := string(payloadBytes)
+	sig := parts[1]
+
+	valid := false
+	for _, secret := range secrets.all() {
+		if subtle.ConstantTimeCompare([]byte(sign(payload, secret)), []byte(sig)) == 1 {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", fmt.Errorf("auth: invalid session signature")
+	}
+
+	fields := strings.SplitN(payload, ".", 2)
+	if len(fields) != 2 {
+		return "", fmt.Errorf("auth: malformed session payload")
+	}
+	userID := fields[0]
+
+	expiryUnix, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("auth: malformed session expiry: %w", err)
+	}
+	if time.Now().After(time.Unix(expiryUnix, 0)) {
+		return "", fmt.Errorf("auth: session expired")
+	}
+
+	return userID, nil
+}
+
+// randomToken returns a random, URL-safe token, used for both CSRF tokens
+// and anywhere else a fresh opaque value is needed.
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("auth: generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// contextKey avoids collisions with context keys from other packages.
+type contextKey int
+
+const userIDContextKey contextKey = iota
+
+// UserIDFromContext returns the authenticated user's id, as set by
+// AuthMiddleware.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(userIDContextKey).(string)
+	return id, ok
+}
+
+// AuthMiddleware requires a valid session cookie, putting the authenticated
+// user's id in the request context for downstream handlers. Requests
+// without a valid session get a 401 and are not passed through.
+func AuthMiddleware(secrets Secrets) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cookie, err := r.Cookie(SessionCookieName)
+			if err != nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			userID, err := ParseSessionCookie(cookie.Value, secrets)
+			if err != nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userIDContextKey, userID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
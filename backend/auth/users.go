@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User is a registered account. PasswordHash is a bcrypt hash, never the
+// plaintext password.
+type User struct {
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"passwordHash"`
+}
+
+// ErrUserNotFound is returned when no user exists with the given username
+// (Authenticate) or id (Get).
+var ErrUserNotFound = fmt.Errorf("auth: user not found")
+
+// ErrUsernameTaken is returned by Register when the username is already in
+// use.
+var ErrUsernameTaken = fmt.Errorf("auth: username already taken")
+
+// UserStore is a concurrency-safe, append-only JSON-Lines store of user
+// accounts, keyed by username. It follows the same replay-on-open pattern as
+// backend/store.Store.
+type UserStore struct {
+	mu sync.RWMutex
+
+	path string
+	file *os.File
+
+	byUsername map[string]*User
+}
+
+// OpenUserStore opens (creating if necessary) the user log at path and
+// replays it to rebuild the in-memory index.
+func OpenUserStore(path string) (*UserStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("auth: open %s: %w", path, err)
+	}
+
+	s := &UserStore{path: path, file: f, byUsername: make(map[string]*User)}
+	if err := s.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *UserStore) replay() error {
+	if _, err := s.file.Seek(0, 0); err != nil {
+		return fmt.Errorf("auth: seek %s: %w", s.path, err)
+	}
+
+	scanner := bufio.NewScanner(s.file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var u User
+		if err := json.Unmarshal(line, &u); err != nil {
+			return fmt.Errorf("auth: corrupt user entry in %s: %w", s.path, err)
+		}
+		user := u
+		s.byUsername[u.Username] = &user
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("auth: read %s: %w", s.path, err)
+	}
+
+	if _, err := s.file.Seek(0, 2); err != nil {
+		return fmt.Errorf("auth: seek %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// newUserID returns a random, URL-safe user id.
+func newUserID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("auth: generate id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Register creates a new user with the given username and password,
+// hashing the password with bcrypt before it is ever written to disk.
+func (s *UserStore) Register(username, password string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.byUsername[username]; exists {
+		return nil, ErrUsernameTaken
+	}
+
+	id, err := newUserID()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("auth: hash password: %w", err)
+	}
+
+	user := &User{ID: id, Username: username, PasswordHash: string(hash)}
+
+	line, err := json.Marshal(user)
+	if err != nil {
+		return nil, fmt.Errorf("auth: marshal user: %w", err)
+	}
+	line = append(line, '\n')
+
+	if _, err := s.file.Write(line); err != nil {
+		return nil, fmt.Errorf("auth: write %s: %w", s.path, err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return nil, fmt.Errorf("auth: fsync %s: %w", s.path, err)
+	}
+
+	s.byUsername[username] = user
+	return user, nil
+}
+
+// Authenticate returns the user with the given username if password matches
+// its stored hash, or ErrUserNotFound / bcrypt's mismatch error otherwise.
+func (s *UserStore) Authenticate(username, password string) (*User, error) {
+	s.mu.RLock()
+	user, ok := s.byUsername[username]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, fmt.Errorf("auth: invalid credentials: %w", err)
+	}
+	return user, nil
+}
+
+// Get returns the user with the given id, or ErrUserNotFound.
+func (s *UserStore) Get(id string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.byUsername {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
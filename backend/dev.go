@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// devMode toggles the live-reload behavior wired up by StartDev / the -dev
+// flag: homePage injects the reload script, and /__dev/reload is mounted.
+var devMode bool
+
+// reloadDebounce coalesces bursts of filesystem events (e.g. an editor's
+// save-then-rename) into a single broadcast.
+const reloadDebounce = 100 * time.Millisecond
+
+// liveReloadScript is injected into homePage's response in dev mode. It
+// subscribes to the SSE stream and reloads the page on any "change" event.
+const liveReloadScript = `<script>
+new EventSource("/__dev/reload").addEventListener("change", function() {
+	location.reload();
+});
+</script>`
+
+// devReloadBroker fans out static-asset change notifications to connected
+// /__dev/reload SSE clients.
+type devReloadBroker struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+// newDevReloadBroker creates an empty broker.
+func newDevReloadBroker() *devReloadBroker {
+	return &devReloadBroker{clients: make(map[chan struct{}]struct{})}
+}
+
+// subscribe registers a new client channel and returns it along with an
+// unsubscribe func the caller must invoke when the connection closes.
+func (b *devReloadBroker) subscribe() (ch chan struct{}, unsubscribe func()) {
+	ch = make(chan struct{}, 1)
+
+	b.mu.Lock()
+	b.clients[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.clients, ch)
+		b.mu.Unlock()
+		close(ch)
+	}
+}
+
+// broadcast notifies every subscribed client. Slow or stalled clients are
+// skipped rather than blocking the broadcaster.
+func (b *devReloadBroker) broadcast() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// handleDevReload serves the /__dev/reload SSE stream. Each connected client
+// receives a "change" event whenever the broker broadcasts.
+func (b *devReloadBroker) handleDevReload(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch, unsubscribe := b.subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			if _, err := w.Write([]byte("event: change\ndata: reload\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// watchStatic watches dir for filesystem changes and broadcasts a single
+// reload notification per debounced burst of events. The watcher and its
+// goroutine run until the process exits; nothing stops them earlier. It is
+// started only in dev mode (StartDev or Start's -dev flag).
+func watchStatic(dir string, broker *devReloadBroker) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				slog.Debug("static file changed", "path", event.Name, "op", event.Op)
+				if timer == nil {
+					timer = time.AfterFunc(reloadDebounce, broker.broadcast)
+				} else {
+					timer.Reset(reloadDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error("static watcher error", "error", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// injectLiveReloadScript inserts the live-reload script immediately before
+// the closing </body> tag, or appends it if none is found.
+func injectLiveReloadScript(html []byte) []byte {
+	const marker = "</body>"
+	if idx := bytes.LastIndex(html, []byte(marker)); idx != -1 {
+		out := make([]byte, 0, len(html)+len(liveReloadScript))
+		out = append(out, html[:idx]...)
+		out = append(out, []byte(liveReloadScript)...)
+		out = append(out, html[idx:]...)
+		return out
+	}
+	return append(html, []byte(liveReloadScript)...)
+}
+
+// StartDev runs the server with live-reload enabled: it watches staticDir
+// for changes and pushes them to connected browsers over SSE so the
+// frontend can be iterated without manual refreshes. It otherwise behaves
+// like Start.
+func StartDev() {
+	devMode = true
+	Start()
+}
@@ -0,0 +1,196 @@
+// Package middleware provides composable http.Handler wrappers applied to
+// the backend's router: panic recovery, access logging, and transparent
+// gzip are applied to every route via r.Use(...); ETag/Last-Modified
+// caching is applied per-route, keyed off that route's own data source.
+package middleware
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Recovery recovers from a panic anywhere downstream, logs it with a stack
+// trace, and responds with a plain 500 instead of taking the process down.
+func Recovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				slog.Error("panic recovered",
+					"panic", rec,
+					"method", r.Method,
+					"path", r.URL.Path,
+					"stack", string(debug.Stack()),
+				)
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, "Internal server error")
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder wraps a ResponseWriter to capture the status code written,
+// since http.ResponseWriter has no getter for it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// AccessLog logs the method, path, status, and duration of every request.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		slog.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration", time.Since(start),
+		)
+	})
+}
+
+// gzipResponseWriter transparently gzips the response body when the
+// downstream handler's Content-Type is JSON.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	request *http.Request
+	gz      *gzip.Writer // non-nil once the decision to compress has been made and applied
+	decided bool
+}
+
+// WriteHeader inspects the Content-Type set by the handler and, if it's JSON
+// and the client accepts gzip, swaps in a gzip.Writer before the headers are
+// sent.
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	w.prepare()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	w.prepare()
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *gzipResponseWriter) prepare() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	if !strings.Contains(w.request.Header.Get("Accept-Encoding"), "gzip") {
+		return
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		return
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Del("Content-Length") // length is no longer known once compressed
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+}
+
+func (w *gzipResponseWriter) Close() error {
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// Flush lets streaming handlers (such as the dev-mode /__dev/reload SSE
+// stream) push data through AccessLog and Gzip: each wrapper flushes what
+// it buffers, then the ResponseWriter beneath it.
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *gzipResponseWriter) Flush() {
+	w.prepare()
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Gzip transparently compresses JSON responses for clients that advertise
+// gzip support via Accept-Encoding.
+func Gzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gz := &gzipResponseWriter{ResponseWriter: w, request: r}
+		defer gz.Close()
+		next.ServeHTTP(gz, r)
+	})
+}
+
+// Caching adds ETag and Last-Modified headers derived from stat, and
+// answers with 304 Not Modified when the client's If-None-Match or
+// If-Modified-Since headers show the underlying data hasn't changed. stat
+// is called on every request so it can reflect a backend whose content
+// changes after startup; its signature matches storage.Backend.Stat, so a
+// method value like datasetBackend.Stat can be passed directly.
+//
+// private must be true whenever stat's ETag is derived from shared backend
+// state but the response body itself differs per caller (e.g. filtered to
+// the authenticated user) -- otherwise a shared or proxying cache could
+// serve one caller's cached body to another whose conditional request
+// happens to match the same ETag. It sets Cache-Control: private, which
+// restricts caching to the requester's own client.
+func Caching(stat func(ctx context.Context) (modTime time.Time, size int64, etag string, err error), private bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			modTime, _, rawETag, err := stat(r.Context())
+			if err != nil {
+				// Nothing to key the cache off of; fall through uncached.
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if private {
+				w.Header().Set("Cache-Control", "private")
+			}
+
+			etag := fmt.Sprintf(`"%s"`, rawETag)
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+
+			if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			if since := r.Header.Get("If-Modified-Since"); since != "" {
+				if t, err := http.ParseTime(since); err == nil && !modTime.After(t) {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
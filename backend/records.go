@@ -0,0 +1,345 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/MoshkovSergey/crm/backend/auth"
+	"github.com/MoshkovSergey/crm/backend/storage"
+	"github.com/MoshkovSergey/crm/backend/store"
+)
+
+// ownerField is the JSON field used to scope records to the user that
+// created them.
+const ownerField = "ownerId"
+
+// recordStore is the backing store for /api/records. It is opened in Start.
+var recordStore *store.Store
+
+// datasetBackend is the pluggable backend for the /data compatibility
+// shim. It is selected from config in Start.
+var datasetBackend storage.Backend
+
+// datasetMu serializes postData's load-merge-save cycle. storage.Backend
+// has no atomic read-modify-write of its own, so without this two
+// concurrent POSTs could both Load the same snapshot and the second Save
+// would silently discard the first's merge.
+var datasetMu sync.Mutex
+
+// compactionInterval is how often the background compactor checks whether
+// the log has accumulated enough churn to be worth rewriting.
+const compactionInterval = 5 * time.Minute
+
+// runCompactor periodically rewrites the log once its tombstone/update ratio
+// crosses store.CompactionRatio, so a long-running server doesn't carry an
+// ever-growing history of dead entries.
+func runCompactor(s *store.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !s.ShouldCompact() {
+			continue
+		}
+		if err := s.Compact(); err != nil {
+			slog.Error("compact record log", "error", err)
+			continue
+		}
+		slog.Info("compacted record log")
+	}
+}
+
+// writeJSON marshals v and writes it with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("marshal response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// ownerOf returns the ownerId recorded in a stored record, or "" if absent.
+func ownerOf(data json.RawMessage) string {
+	var withOwner struct {
+		OwnerID string `json:"ownerId"`
+	}
+	if err := json.Unmarshal(data, &withOwner); err != nil {
+		return ""
+	}
+	return withOwner.OwnerID
+}
+
+// withOwner returns data with its ownerId field set to owner, overwriting
+// any value the client supplied.
+func withOwner(data []byte, owner string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		fields = map[string]json.RawMessage{}
+	}
+
+	ownerJSON, err := json.Marshal(owner)
+	if err != nil {
+		return nil, err
+	}
+	fields[ownerField] = ownerJSON
+
+	return json.Marshal(fields)
+}
+
+// listRecords handles GET /api/records, returning every live record owned
+// by the authenticated user.
+func listRecords(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	owned := make([]json.RawMessage, 0)
+	for _, rec := range recordStore.List() {
+		if ownerOf(rec) == userID {
+			owned = append(owned, rec)
+		}
+	}
+	writeJSON(w, http.StatusOK, owned)
+}
+
+// createRecordHandler handles POST /api/records, creating a record from the
+// request body, scoped to the authenticated user, and responding with the id
+// it was assigned.
+func createRecordHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		slog.Error("read request body", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Bad request")
+		return
+	}
+
+	scoped, err := withOwner(body, userID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Bad request")
+		return
+	}
+
+	id, err := recordStore.Create(scoped)
+	if err != nil {
+		slog.Error("create record", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]any{"id": id})
+}
+
+// getRecordHandler handles GET /api/records/{id}, scoped to records owned
+// by the authenticated user.
+func getRecordHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	rec, err := recordStore.Get(id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && ownerOf(rec) != userID) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "Not found")
+		return
+	} else if err != nil {
+		slog.Error("get record", "error", err, "id", id)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rec)
+}
+
+// updateRecordHandler handles PUT /api/records/{id}, scoped to records
+// owned by the authenticated user.
+func updateRecordHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	existing, err := recordStore.Get(id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && ownerOf(existing) != userID) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "Not found")
+		return
+	} else if err != nil {
+		slog.Error("get record", "error", err, "id", id)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		slog.Error("read request body", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Bad request")
+		return
+	}
+
+	scoped, err := withOwner(body, userID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Bad request")
+		return
+	}
+
+	if err := recordStore.Update(id, scoped); err != nil {
+		slog.Error("update record", "error", err, "id", id)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteRecordHandler handles DELETE /api/records/{id}, scoped to records
+// owned by the authenticated user.
+func deleteRecordHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	existing, err := recordStore.Get(id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && ownerOf(existing) != userID) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "Not found")
+		return
+	} else if err != nil {
+		slog.Error("get record", "error", err, "id", id)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+
+	if err := recordStore.Delete(id); err != nil {
+		slog.Error("delete record", "error", err, "id", id)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getData is the /data compatibility shim for GET requests: it loads the
+// materialized dataset from the configured storage.Backend and returns only
+// the entries owned by the authenticated user, matching the shape the old
+// flat-file endpoint returned but scoped the same way listRecords is.
+func getData(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	data, err := datasetBackend.Load(r.Context())
+	if err != nil {
+		slog.Error("load dataset", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+
+	var all []json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		slog.Error("parse dataset", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+
+	owned := make([]json.RawMessage, 0)
+	for _, rec := range all {
+		if ownerOf(rec) == userID {
+			owned = append(owned, rec)
+		}
+	}
+	writeJSON(w, http.StatusOK, owned)
+}
+
+// postData is the /data compatibility shim for POST requests: it replaces
+// the authenticated user's entries in the dataset with the posted body,
+// leaving every other user's entries untouched, matching the old endpoint's
+// truncate-and-rewrite behavior scoped the same way the /api/records
+// handlers are.
+func postData(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		slog.Error("read request body", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Bad request")
+		return
+	}
+
+	var incoming []json.RawMessage
+	if err := json.Unmarshal(body, &incoming); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Bad request")
+		return
+	}
+
+	datasetMu.Lock()
+	defer datasetMu.Unlock()
+
+	data, err := datasetBackend.Load(r.Context())
+	if err != nil {
+		slog.Error("load dataset", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+	var existing []json.RawMessage
+	if err := json.Unmarshal(data, &existing); err != nil {
+		slog.Error("parse dataset", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+
+	merged := make([]json.RawMessage, 0, len(existing)+len(incoming))
+	for _, rec := range existing {
+		if ownerOf(rec) != userID {
+			merged = append(merged, rec)
+		}
+	}
+	for _, rec := range incoming {
+		scoped, err := withOwner(rec, userID)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, "Bad request")
+			return
+		}
+		merged = append(merged, scoped)
+	}
+
+	out, err := json.Marshal(merged)
+	if err != nil {
+		slog.Error("marshal dataset", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+
+	if err := datasetBackend.Save(r.Context(), out); err != nil {
+		slog.Error("save dataset", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Internal server error")
+		return
+	}
+}
@@ -1,162 +1,224 @@
 package backend
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
-)
 
-const dataFilePath = "../data/data.txt"
-
-// ensureDataFileExists creates the data file if it does not exist or
-// opens it if it already exists. It also ensures the file contains an
-// empty JSON array.
-func ensureDataFileExists() {
-	// Check if the data file exists
-	if _, err := os.Stat(dataFilePath); os.IsNotExist(err) {
-		// If the file does not exist, create it
-		_, err := os.Create(dataFilePath)
-		if err != nil {
-			panic(err)
-		}
-	} else if err != nil {
-		// If there was an error checking the file existence, panic
-		panic(err)
-	} else {
-		// If the file exists, open it in append mode for writing
-		dataFile, err := os.OpenFile(dataFilePath, os.O_APPEND|os.O_WRONLY, 0644)
-		if err != nil {
-			log.Fatal(err)
-		}
+	"github.com/MoshkovSergey/crm/backend/auth"
+	"github.com/MoshkovSergey/crm/backend/middleware"
+	"github.com/MoshkovSergey/crm/backend/store"
+)
 
-		// Close the file when the function returns
-		defer dataFile.Close()
+// dataFilePath and staticDir are resolved from config in Start. They default
+// to the historical on-disk layout so callers that skip Start's flag/env
+// plumbing (e.g. tests) keep working.
+var (
+	dataFilePath = "../data/data.txt"
+	staticDir    = "../static"
+)
 
-		// If the file is empty, write an empty JSON array
-		stat, err := dataFile.Stat()
-		if err != nil {
-			log.Fatal(err)
-		}
-		if stat.Size() == 0 {
-			_, err = dataFile.Write([]byte("[]"))
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
-	}
-}
+// config holds the server's runtime configuration. Values are resolved from
+// flags first, falling back to environment variables, and finally to the
+// defaults below. The timeouts and dev are set by flag only; they have no
+// environment variable.
+type config struct {
+	addr            string
+	staticDir       string
+	dataFile        string
+	dev             bool
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	shutdownTimeout time.Duration
 
-// getData handles GET requests for the /data endpoint. It opens the data file, sends its contents to the client,
-// and writes the client's request body to the data file.
-// Parameters:
-// - w: http.ResponseWriter - the response writer used to send the response to the client
-// - r: *http.Request - the client's request
-func getData(w http.ResponseWriter, r *http.Request) {
-	// Open the data file
-	dataFile, err := os.Open(dataFilePath)
-	if err != nil {
-		// If there was an error opening the file, log the error and send an internal server error response
-		log.Fatal("file open on get", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		io.WriteString(w, "Internal server error")
-		return
-	}
-	defer dataFile.Close() // Close the file when the function returns
+	// storageBackend selects the implementation backing the /data
+	// compatibility endpoint: "filesystem" (default), "sqlite", or "s3".
+	// datasetFile is its own file, separate from dataFile (the
+	// record store's append-only log): the two are unrelated documents and
+	// must never alias the same path, or the dataset backend's
+	// truncate-and-rewrite Save would corrupt the record log.
+	storageBackend string
+	datasetFile    string
+	sqliteDSN      string
+	s3Bucket       string
+	s3Key          string
 
-	// Send the data file's contents to the client
-	http.ServeContent(w, r, dataFilePath, time.Now(), dataFile)
+	usersFile      string
+	sessionSecrets string // comma-separated; first is used to sign, rest accepted during rotation
+}
 
-	// Write the client's request body to the data file
-	_, err = io.Copy(dataFile, r.Body)
-	if err != nil {
-		// If there was an error writing to the file, log the error and send an internal server error response
-		log.Fatal("copy from request", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		io.WriteString(w, "Internal server error")
-		return
+// defaultConfig returns the conf-style defaults used when neither a flag nor
+// an environment variable sets a value.
+func defaultConfig() config {
+	return config{
+		addr:            "127.0.0.1:8080",
+		staticDir:       staticDir,
+		dataFile:        dataFilePath,
+		readTimeout:     15 * time.Second,
+		writeTimeout:    15 * time.Second,
+		shutdownTimeout: 10 * time.Second,
+		storageBackend:  "filesystem",
+		datasetFile:     "../data/dataset.json",
+		sqliteDSN:       "../data/crm.sqlite",
+		usersFile:       "../data/users.txt",
 	}
 }
 
+// loadConfig parses flags (falling back to env vars, then defaults) into a
+// config. It is split out from Start so the resolution logic can be tested
+// on its own.
+func loadConfig(args []string) config {
+	cfg := defaultConfig()
 
-// postData handles POST requests for the /data endpoint. It opens the data file in write-only mode and truncates the
-// file. It then copies the contents of the request body to the data file.
-// Parameters:
-// - w: http.ResponseWriter - the response writer used to send the response to the client
-// - r: *http.Request - the client's request
-func postData(w http.ResponseWriter, r *http.Request) {
+	fs := flag.NewFlagSet("backend", flag.ContinueOnError)
+	fs.StringVar(&cfg.addr, "addr", envOr("CRM_ADDR", cfg.addr), "address for the HTTP server to listen on")
+	fs.StringVar(&cfg.staticDir, "static-dir", envOr("CRM_STATIC_DIR", cfg.staticDir), "directory containing the static frontend")
+	fs.StringVar(&cfg.dataFile, "data-file", envOr("CRM_DATA_FILE", cfg.dataFile), "path to the data file")
+	fs.DurationVar(&cfg.readTimeout, "read-timeout", cfg.readTimeout, "maximum duration for reading an entire request")
+	fs.DurationVar(&cfg.writeTimeout, "write-timeout", cfg.writeTimeout, "maximum duration before timing out writes of a response")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", cfg.shutdownTimeout, "time to wait for in-flight requests to drain on shutdown")
+	fs.BoolVar(&cfg.dev, "dev", devMode, "enable live-reload dev mode for the static frontend")
+	fs.StringVar(&cfg.storageBackend, "storage-backend", envOr("CRM_STORAGE_BACKEND", cfg.storageBackend), "backend for the /data endpoint: filesystem, sqlite, or s3")
+	fs.StringVar(&cfg.datasetFile, "dataset-file", envOr("CRM_DATASET_FILE", cfg.datasetFile), "path to the dataset file, used when storage-backend=filesystem (must not be the same file as -data-file)")
+	fs.StringVar(&cfg.sqliteDSN, "sqlite-dsn", envOr("CRM_SQLITE_DSN", cfg.sqliteDSN), "SQLite DSN, used when storage-backend=sqlite")
+	fs.StringVar(&cfg.s3Bucket, "s3-bucket", envOr("CRM_S3_BUCKET", cfg.s3Bucket), "S3 bucket, used when storage-backend=s3")
+	fs.StringVar(&cfg.s3Key, "s3-key", envOr("CRM_S3_KEY", cfg.s3Key), "S3 object key, used when storage-backend=s3")
+	fs.StringVar(&cfg.usersFile, "users-file", envOr("CRM_USERS_FILE", cfg.usersFile), "path to the user account log")
+	fs.StringVar(&cfg.sessionSecrets, "session-secrets", envOr("CRM_SESSION_SECRETS", cfg.sessionSecrets), "comma-separated HMAC secrets for session cookies; first signs, rest are accepted during rotation")
+	// Flag parsing errors (e.g. -h) are already reported by the flag package;
+	// Start runs with whatever was parsed so far.
+	_ = fs.Parse(args)
 
-	// Open the data file in write-only mode and truncate the file.
-	// If the file doesn't exist, it will be created.
-	dataFile, err := os.OpenFile(dataFilePath, os.O_WRONLY|os.O_TRUNC, 0644)
+	return cfg
+}
 
-	// If there was an error opening the file, log the error and send an internal server error response
-	if err != nil {
-		log.Println("file open on post func", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		io.WriteString(w, "Internal server error")
-		return
+// envOr returns the value of the named environment variable, or fallback if
+// it is unset.
+func envOr(name, fallback string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
 	}
+	return fallback
+}
 
-	// Close the file when the function returns
-	defer dataFile.Close()
-
-	// Copy the contents of the request body to the data file
-	_, err = io.Copy(dataFile, r.Body)
-
-	// If there was an error writing to the file, log the error and send an internal server error response
-	if err != nil {
-		log.Println("copy from request", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		io.WriteString(w, "Internal server error")
-		return
+// ephemeralSecret returns a random value suitable for auth.LoadSecrets, for
+// use when no CRM_SESSION_SECRETS has been configured.
+func ephemeralSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand failing is unrecoverable; the caller can't safely
+		// issue sessions without a real secret.
+		panic(err)
 	}
+	return hex.EncodeToString(b)
 }
 
-// homePage handles GET requests for the root endpoint ("/"). It opens the "index.html" file in the "static" directory,
+// homePage handles GET requests for the root endpoint ("/"). It opens the "index.html" file in the static directory,
 // reads its contents, and sends them to the client.
 // Parameters:
 // - w: http.ResponseWriter - the response writer used to send the response to the client
 // - r: *http.Request - the client's request
 func homePage(w http.ResponseWriter, r *http.Request) {
-	// Open the "index.html" file in read-only mode.
-	indexFile, err := os.Open("../static/index.html")
+	// Read the "index.html" file.
+	html, err := os.ReadFile(staticDir + "/index.html")
 	if err != nil {
-		// If there was an error opening the file, log the error and send an internal server error response.
-		log.Fatal("file open on get", err.Error())
+		// If there was an error reading the file, log the error and send an internal server error response.
+		slog.Error("read index.html", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		io.WriteString(w, "Internal server error")
 		return
 	}
-	defer indexFile.Close() // Close the file when the function returns.
 
-	// Copy the contents of the file to the response writer.
-	io.Copy(w, indexFile)
+	// In dev mode, inject the live-reload script so the browser refreshes
+	// whenever watchStatic observes a change under staticDir.
+	if devMode {
+		html = injectLiveReloadScript(html)
+	}
 
-	// Serve the file with the given file name, modification time, and content.
-	http.ServeContent(w, r, "index.html", time.Now(), indexFile)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(html)
 }
 
-
 // Start starts the HTTP server that handles requests for the application.
-// It creates a new router, sets up the routes, and starts the server.
+// It reads its configuration from flags/environment variables, creates a new
+// router, sets up the routes, and blocks until the process receives an
+// interrupt or termination signal, at which point it drains in-flight
+// requests and shuts down cleanly.
 func Start() {
-	// Ensure that the data file exists.
-	ensureDataFileExists()
+	cfg := loadConfig(os.Args[1:])
+	dataFilePath = cfg.dataFile
+	staticDir = cfg.staticDir
+	devMode = cfg.dev
+
+	if cfg.storageBackend == "" || cfg.storageBackend == "filesystem" {
+		if cfg.datasetFile == cfg.dataFile {
+			slog.Error("-dataset-file must not be the same path as -data-file: the dataset backend truncates and overwrites it, which would corrupt the record store's append-only log", "path", cfg.dataFile)
+			os.Exit(1)
+		}
+	}
+
+	// Open the record store, replaying its log to rebuild the in-memory index.
+	s, err := store.Open(dataFilePath)
+	if err != nil {
+		slog.Error("open record store", "error", err)
+		os.Exit(1)
+	}
+	recordStore = s
+	go runCompactor(recordStore, compactionInterval)
+
+	// Select and open the backend for the /data compatibility endpoint.
+	backend, err := newDatasetBackend(context.Background(), cfg)
+	if err != nil {
+		slog.Error("open dataset backend", "error", err)
+		os.Exit(1)
+	}
+	datasetBackend = backend
+
+	// Open the user store and resolve session-signing secrets.
+	users, err := auth.OpenUserStore(cfg.usersFile)
+	if err != nil {
+		slog.Error("open user store", "error", err)
+		os.Exit(1)
+	}
+	secrets, err := auth.LoadSecrets(cfg.sessionSecrets)
+	if err != nil {
+		slog.Warn("no session secret configured, generating an ephemeral one; sessions will not survive a restart", "error", err)
+		ephemeral, genErr := auth.LoadSecrets(ephemeralSecret())
+		if genErr != nil {
+			slog.Error("generate ephemeral session secret", "error", genErr)
+			os.Exit(1)
+		}
+		secrets = ephemeral
+	}
+	authHandlers := &auth.Handlers{Users: users, Secrets: secrets}
 
 	// Create a new router.
 	r := mux.NewRouter()
 
+	// Apply the shared middleware chain: panic recovery first so it covers
+	// everything below it, then access logging, then the response-shaping
+	// layers.
+	r.Use(middleware.Recovery)
+	r.Use(middleware.AccessLog)
+	r.Use(middleware.Gzip)
+
 	// Create a new server.
 	srv := &http.Server{
-		Handler: r, // Set the router as the server's handler.
-		Addr:    "127.0.0.1:8080", // Set the server's address.
-		// Enforce timeouts for the server to avoid resource leaks.
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
+		Handler:      r,        // Set the router as the server's handler.
+		Addr:         cfg.addr, // Set the server's address.
+		WriteTimeout: cfg.writeTimeout,
+		ReadTimeout:  cfg.readTimeout,
 	}
 
 	// Define the routes.
@@ -164,21 +226,79 @@ func Start() {
 	// Handle GET requests for the root endpoint ("/").
 	r.HandleFunc("/", homePage)
 
-	// Handle GET requests for the "/data" endpoint.
-	r.Methods("GET").Path("/data").HandlerFunc(getData)
+	// Handle the open auth endpoints: anyone can try to register or log in.
+	r.Methods("POST").Path("/api/auth/register").HandlerFunc(authHandlers.RegisterHandler)
+	r.Methods("POST").Path("/api/auth/login").HandlerFunc(authHandlers.LoginHandler)
+
+	// withAuth requires a valid session; protect additionally requires a
+	// matching CSRF token, for routes that accept unsafe methods.
+	withAuth := auth.AuthMiddleware(secrets)
+	protect := func(h http.HandlerFunc) http.Handler {
+		return withAuth(auth.CSRFMiddleware(h))
+	}
+
+	// /api/auth/me only needs a valid session, not CSRF protection, since
+	// it's a safe GET.
+	r.Methods("GET").Path("/api/auth/me").Handler(withAuth(http.HandlerFunc(authHandlers.MeHandler)))
+
+	// Everything that reads or writes record data requires a session and,
+	// for unsafe methods, a matching CSRF token.
+
+	// Handle GET requests for the "/data" endpoint. Caching is scoped to
+	// this route alone: its ETag/Last-Modified describe the dataset
+	// backend's contents, which has nothing to do with any other route.
+	// getData filters that dataset down to the caller's own records, so
+	// the cached response is marked private -- the backend-wide ETag must
+	// not let a shared cache serve one user's filtered body to another.
+	dataCaching := middleware.Caching(datasetBackend.Stat, true)
+	r.Methods("GET").Path("/data").Handler(protect(dataCaching(http.HandlerFunc(getData)).ServeHTTP))
 
 	// Handle POST requests for the "/data" endpoint.
-	r.Methods("POST").Path("/data").HandlerFunc(postData)
+	r.Methods("POST").Path("/data").Handler(protect(postData))
+
+	// Handle the REST CRUD routes for records.
+	r.Methods("GET").Path("/api/records").Handler(protect(listRecords))
+	r.Methods("POST").Path("/api/records").Handler(protect(createRecordHandler))
+	r.Methods("GET").Path("/api/records/{id}").Handler(protect(getRecordHandler))
+	r.Methods("PUT").Path("/api/records/{id}").Handler(protect(updateRecordHandler))
+	r.Methods("DELETE").Path("/api/records/{id}").Handler(protect(deleteRecordHandler))
 
 	// Handle all requests for the "/static/" prefix.
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("../static"))))
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
 
-	// Log the server's address and start the server.
-	log.Printf("Server listening on %s\n", srv.Addr)
-	log.Fatal(srv.ListenAndServe())
+	if devMode {
+		broker := newDevReloadBroker()
+		if err := watchStatic(staticDir, broker); err != nil {
+			slog.Error("start static watcher", "error", err)
+			os.Exit(1)
+		}
+		r.HandleFunc("/__dev/reload", broker.handleDevReload)
+		slog.Info("live-reload dev mode enabled", "watching", staticDir)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Run the server in the background so this goroutine can wait on ctx.
+	go func() {
+		slog.Info("server listening", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server error", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Block until an interrupt or termination signal arrives.
+	<-ctx.Done()
+	stop()
+	slog.Info("shutdown signal received, draining connections", "timeout", cfg.shutdownTimeout)
 
-	// Older code, removed.
-	// http.HandleFunc("/data", getData)
-	// http.HandleFunc("/", homePage)
-	// http.ListenAndServe(":8080", nil)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("graceful shutdown failed", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("server stopped")
 }
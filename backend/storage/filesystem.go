@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// FilesystemBackend stores the dataset as a single file on local disk. It is
+// the original, default backend.
+type FilesystemBackend struct {
+	path string
+}
+
+// NewFilesystemBackend returns a Backend backed by the file at path,
+// creating it with an empty JSON array if it does not already exist.
+func NewFilesystemBackend(path string) (*FilesystemBackend, error) {
+	b := &FilesystemBackend{path: path}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := b.Save(context.Background(), []byte("[]")); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("storage: stat %s: %w", path, err)
+	}
+
+	return b, nil
+}
+
+// Load reads the whole file into memory.
+func (b *FilesystemBackend) Load(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		return nil, fmt.Errorf("storage: read %s: %w", b.path, err)
+	}
+	return data, nil
+}
+
+// Save truncates the file and writes data, fsyncing before it returns.
+func (b *FilesystemBackend) Save(ctx context.Context, data []byte) error {
+	f, err := os.OpenFile(b.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("storage: open %s: %w", b.path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("storage: write %s: %w", b.path, err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("storage: fsync %s: %w", b.path, err)
+	}
+	return nil
+}
+
+// Stat reports the file's modification time, size, and a digest-based ETag.
+func (b *FilesystemBackend) Stat(ctx context.Context) (time.Time, int64, string, error) {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return time.Time{}, 0, "", fmt.Errorf("storage: stat %s: %w", b.path, err)
+	}
+	return info.ModTime(), info.Size(), etagFor(info.ModTime(), info.Size()), nil
+}
+
+// etagFor derives a short, stable token from a modification time and size.
+func etagFor(modTime time.Time, size int64) string {
+	sum := sha256.Sum256([]byte(modTime.UTC().Format(time.RFC3339Nano) + "-" + strconv.FormatInt(size, 10)))
+	return hex.EncodeToString(sum[:8])
+}
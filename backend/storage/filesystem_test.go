@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilesystemBackend_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dataset.json")
+	ctx := context.Background()
+
+	b, err := NewFilesystemBackend(path)
+	if err != nil {
+		t.Fatalf("NewFilesystemBackend: %v", err)
+	}
+
+	data, err := b.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if string(data) != "[]" {
+		t.Errorf("Load() on a fresh backend = %s, want []", data)
+	}
+
+	if err := b.Save(ctx, []byte(`[{"id":"1"}]`)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	data, err = b.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load after Save: %v", err)
+	}
+	if string(data) != `[{"id":"1"}]` {
+		t.Errorf("Load() after Save = %s, want [{\"id\":\"1\"}]", data)
+	}
+
+	modTime, size, etag, err := b.Stat(ctx)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if size != int64(len(`[{"id":"1"}]`)) {
+		t.Errorf("Stat size = %d, want %d", size, len(`[{"id":"1"}]`))
+	}
+	if modTime.IsZero() {
+		t.Error("Stat modTime is zero")
+	}
+	if etag == "" {
+		t.Error("Stat etag is empty")
+	}
+}
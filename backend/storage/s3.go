@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3API is the subset of *s3.Client this backend depends on, so tests can
+// substitute a fake.
+type s3API interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+// S3Backend stores the dataset as a single object in an S3-compatible
+// bucket, using the object's ETag for optimistic concurrency: Save only
+// overwrites the object if it still matches the ETag Save last observed, so
+// two instances racing to write don't silently clobber each other.
+type S3Backend struct {
+	client s3API
+	bucket string
+	key    string
+
+	mu       sync.Mutex
+	lastETag string // observed on the most recent Load/Stat/Save; used as If-Match on Save
+}
+
+// NewS3Backend returns a Backend that stores its dataset at bucket/key.
+func NewS3Backend(client *s3.Client, bucket, key string) *S3Backend {
+	return &S3Backend{client: client, bucket: bucket, key: key}
+}
+
+// setLastETag records the ETag most recently observed from S3, for use as
+// If-Match on the next Save. Load, Save, and Stat all call this from
+// whatever goroutine is handling a request, so it needs its own lock.
+func (b *S3Backend) setLastETag(etag string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastETag = etag
+}
+
+// getLastETag returns the ETag recorded by setLastETag, or "" if none has
+// been observed yet.
+func (b *S3Backend) getLastETag() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastETag
+}
+
+// Load fetches the object's contents, initializing it to an empty JSON
+// array if it does not exist yet.
+func (b *S3Backend) Load(ctx context.Context) ([]byte, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			if err := b.Save(ctx, []byte("[]")); err != nil {
+				return nil, err
+			}
+			return []byte("[]"), nil
+		}
+		return nil, fmt.Errorf("storage: get s3://%s/%s: %w", b.bucket, b.key, err)
+	}
+	defer out.Body.Close()
+
+	if out.ETag != nil {
+		b.setLastETag(strings.Trim(*out.ETag, `"`))
+	}
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("storage: read s3://%s/%s: %w", b.bucket, b.key, err)
+	}
+	return data, nil
+}
+
+// Save writes data to the object. If a previous Load, Stat, or Save observed
+// an ETag, Save is conditioned on the object still matching it (If-Match), so
+// a concurrent writer's update isn't silently lost.
+func (b *S3Backend) Save(ctx context.Context, data []byte) error {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(b.bucket),
+		Key:         aws.String(b.key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+	if lastETag := b.getLastETag(); lastETag != "" {
+		input.IfMatch = aws.String(lastETag)
+	}
+
+	out, err := b.client.PutObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("storage: put s3://%s/%s: %w", b.bucket, b.key, err)
+	}
+	if out.ETag != nil {
+		b.setLastETag(strings.Trim(*out.ETag, `"`))
+	}
+	return nil
+}
+
+// Stat reports the object's last-modified time, size, and ETag.
+func (b *S3Backend) Stat(ctx context.Context) (time.Time, int64, string, error) {
+	out, err := b.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key),
+	})
+	if err != nil {
+		return time.Time{}, 0, "", fmt.Errorf("storage: head s3://%s/%s: %w", b.bucket, b.key, err)
+	}
+
+	etag := ""
+	if out.ETag != nil {
+		etag = strings.Trim(*out.ETag, `"`)
+		b.setLastETag(etag)
+	}
+
+	var size int64
+	if out.ContentLength != nil {
+		size = *out.ContentLength
+	}
+
+	modTime := time.Time{}
+	if out.LastModified != nil {
+		modTime = *out.LastModified
+	}
+
+	return modTime, size, etag, nil
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSQLiteBackend_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	// A bare ":memory:" DSN gives every new connection its own empty
+	// database, which breaks the moment database/sql opens a second one
+	// from its pool; cache=shared keeps them all pointing at the same
+	// in-memory database for the life of this test.
+	b, err := NewSQLiteBackend("file::memory:?cache=shared")
+	if err != nil {
+		t.Fatalf("NewSQLiteBackend: %v", err)
+	}
+
+	data, err := b.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if string(data) != "[]" {
+		t.Errorf("Load() on a fresh backend = %s, want []", data)
+	}
+
+	if err := b.Save(ctx, []byte(`[{"id":"1"}]`)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	data, err = b.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load after Save: %v", err)
+	}
+	if string(data) != `[{"id":"1"}]` {
+		t.Errorf("Load() after Save = %s, want [{\"id\":\"1\"}]", data)
+	}
+
+	_, size, etag, err := b.Stat(ctx)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if size != int64(len(`[{"id":"1"}]`)) {
+		t.Errorf("Stat size = %d, want %d", size, len(`[{"id":"1"}]`))
+	}
+	if etag == "" {
+		t.Error("Stat etag is empty")
+	}
+}
@@ -0,0 +1,25 @@
+// Package storage defines a pluggable persistence layer for the
+// materialized dataset blob served through the /data compatibility
+// endpoint, so the backend can move off a local flat file without changing
+// its callers.
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Backend loads and saves the dataset as a single JSON document and reports
+// enough metadata for HTTP caching (see backend/middleware.Caching).
+type Backend interface {
+	// Load returns the current dataset. Implementations should return an
+	// empty JSON array, not an error, when no dataset has been saved yet.
+	Load(ctx context.Context) ([]byte, error)
+
+	// Save replaces the dataset in its entirety.
+	Save(ctx context.Context, data []byte) error
+
+	// Stat reports the dataset's last-modified time, size, and an opaque
+	// ETag suitable for If-None-Match / If-Match comparisons.
+	Stat(ctx context.Context) (modTime time.Time, size int64, etag string, err error)
+}
@@ -0,0 +1,36 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/MoshkovSergey/crm/backend/storage"
+)
+
+// newDatasetBackend selects and constructs the storage.Backend for the
+// /data compatibility endpoint based on cfg.storageBackend.
+func newDatasetBackend(ctx context.Context, cfg config) (storage.Backend, error) {
+	switch cfg.storageBackend {
+	case "", "filesystem":
+		return storage.NewFilesystemBackend(cfg.datasetFile)
+
+	case "sqlite":
+		return storage.NewSQLiteBackend(cfg.sqliteDSN)
+
+	case "s3":
+		if cfg.s3Bucket == "" || cfg.s3Key == "" {
+			return nil, fmt.Errorf("storage-backend=s3 requires -s3-bucket and -s3-key")
+		}
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("load AWS config: %w", err)
+		}
+		return storage.NewS3Backend(s3.NewFromConfig(awsCfg), cfg.s3Bucket, cfg.s3Key), nil
+
+	default:
+		return nil, fmt.Errorf("unknown storage-backend %q", cfg.storageBackend)
+	}
+}
@@ -0,0 +1,324 @@
+// Package store provides a concurrency-safe, append-only record store for
+// the CRM's data. Records are arbitrary JSON objects keyed by an "id" field;
+// every mutation is appended to a JSON-Lines log on disk and replayed into
+// an in-memory index on startup, so the store survives a crash without a
+// separate durability mechanism.
+package store
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// op identifies the kind of change a log entry records.
+type op string
+
+const (
+	opCreate op = "create"
+	opUpdate op = "update"
+	opDelete op = "delete"
+)
+
+// entry is a single line of the append-only log.
+type entry struct {
+	Op     op              `json:"op"`
+	ID     string          `json:"id"`
+	Record json.RawMessage `json:"record,omitempty"`
+}
+
+// ErrNotFound is returned by Get, Update, and Delete when no record exists
+// with the given id.
+var ErrNotFound = fmt.Errorf("store: record not found")
+
+// Store is a concurrency-safe, append-only JSON-Lines record store. The
+// zero value is not usable; construct one with Open.
+type Store struct {
+	mu sync.RWMutex
+
+	path string
+	file *os.File
+
+	index map[string]json.RawMessage
+	order []string // insertion order, for a stable List
+
+	// appended counts every log entry written (including replayed ones),
+	// so ShouldCompact can compare it against len(index) -- the live
+	// count -- to tell when the log has accumulated enough dead entries
+	// to be worth rewriting.
+	appended int
+}
+
+// CompactionRatio is the appended-to-live ratio above which the log is
+// considered worth compacting.
+const CompactionRatio = 3
+
+// Open opens the JSON-Lines log at path, creating it if necessary, and
+// replays it to rebuild the in-memory index. This is the crash-recovery
+// path: since every mutation is fsynced before its caller returns, replaying
+// the log reconstructs exactly the state as of the last acknowledged write.
+func Open(path string) (*Store, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("store: open %s: %w", path, err)
+	}
+
+	s := &Store{
+		path:  path,
+		file:  f,
+		index: make(map[string]json.RawMessage),
+	}
+
+	if err := s.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// replay reads every entry in the log and applies it to the in-memory
+// index, in order.
+func (s *Store) replay() error {
+	if _, err := s.file.Seek(0, 0); err != nil {
+		return fmt.Errorf("store: seek %s: %w", s.path, err)
+	}
+
+	scanner := bufio.NewScanner(s.file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("store: corrupt log entry in %s: %w", s.path, err)
+		}
+		s.apply(e)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("store: read %s: %w", s.path, err)
+	}
+
+	// Resume appending at the end of the file.
+	if _, err := s.file.Seek(0, 2); err != nil {
+		return fmt.Errorf("store: seek %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// apply mutates the in-memory index for a single log entry. Callers must
+// hold s.mu (or be replay, before any concurrent access is possible).
+func (s *Store) apply(e entry) {
+	s.appended++
+	switch e.Op {
+	case opCreate:
+		if _, exists := s.index[e.ID]; !exists {
+			s.order = append(s.order, e.ID)
+		}
+		s.index[e.ID] = e.Record
+	case opUpdate:
+		if _, exists := s.index[e.ID]; !exists {
+			s.order = append(s.order, e.ID)
+		}
+		s.index[e.ID] = e.Record
+	case opDelete:
+		delete(s.index, e.ID)
+	}
+}
+
+// appendEntry writes e to the log and fsyncs it before returning, so a
+// caller that gets a nil error knows the change will survive a crash.
+func (s *Store) appendEntry(e entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("store: marshal entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("store: write %s: %w", s.path, err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("store: fsync %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// newID returns a random, URL-safe record id.
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("store: generate id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// List returns every live record, in creation order.
+func (s *Store) List() []json.RawMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]json.RawMessage, 0, len(s.index))
+	for _, id := range s.order {
+		if rec, ok := s.index[id]; ok {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// IDs returns the ids of every live record, in creation order.
+func (s *Store) IDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]string, 0, len(s.index))
+	for _, id := range s.order {
+		if _, ok := s.index[id]; ok {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// Get returns the record stored under id, or ErrNotFound.
+func (s *Store) Get(id string) (json.RawMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.index[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return rec, nil
+}
+
+// Create appends a new record and returns the id it was assigned.
+func (s *Store) Create(data json.RawMessage) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.appendEntry(entry{Op: opCreate, ID: id, Record: data}); err != nil {
+		return "", err
+	}
+	s.order = append(s.order, id)
+	s.index[id] = data
+	s.appended++
+	return id, nil
+}
+
+// Update replaces the record stored under id, or returns ErrNotFound if it
+// does not exist.
+func (s *Store) Update(id string, data json.RawMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.index[id]; !ok {
+		return ErrNotFound
+	}
+	if err := s.appendEntry(entry{Op: opUpdate, ID: id, Record: data}); err != nil {
+		return err
+	}
+	s.index[id] = data
+	s.appended++
+	return nil
+}
+
+// Delete removes the record stored under id, or returns ErrNotFound if it
+// does not exist.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.index[id]; !ok {
+		return ErrNotFound
+	}
+	if err := s.appendEntry(entry{Op: opDelete, ID: id}); err != nil {
+		return err
+	}
+	delete(s.index, id)
+	s.appended++
+	return nil
+}
+
+// ShouldCompact reports whether the log has accumulated enough
+// tombstone/update churn relative to its live record count to be worth
+// rewriting.
+func (s *Store) ShouldCompact() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.appended > len(s.index) && s.appended > CompactionRatio*len(s.index)
+}
+
+// Compact rewrites the log to contain exactly one "create" entry per live
+// record, discarding update/delete history. It is safe to call concurrently
+// with reads and writes.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmpPath := s.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("store: create compaction file: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	order := make([]string, 0, len(s.index))
+	for _, id := range s.order {
+		rec, ok := s.index[id]
+		if !ok {
+			continue
+		}
+		line, err := json.Marshal(entry{Op: opCreate, ID: id, Record: rec})
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("store: marshal entry during compaction: %w", err)
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("store: write compaction file: %w", err)
+		}
+		order = append(order, id)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("store: flush compaction file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("store: fsync compaction file: %w", err)
+	}
+	tmp.Close()
+
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("store: close old log: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("store: rename compaction file: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("store: reopen compacted log: %w", err)
+	}
+	s.file = f
+	s.order = order
+	s.appended = len(s.index)
+	return nil
+}